@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rivo/tview"
+)
+
+// runStartupFlow reads filePath and, via one or more passphrase modals,
+// arrives at a decrypted provider list and the AES key/salt pair guarding
+// it. onReady is called once unlocking (and any needed migration) succeeds.
+func runStartupFlow(app *tview.Application, filePath string, onReady func(providers []Provider, key, salt []byte)) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		showSetPassphraseModal(app, "Welcome! Set a passphrase to protect your providers.", func(passphrase string) {
+			salt, err := newSalt()
+			if err != nil {
+				showStartupError(app, err)
+				return
+			}
+			key := deriveKey(passphrase, salt)
+			if err := persistEncrypted(nil, key, salt, filePath); err != nil {
+				zeroize(key)
+				showStartupError(app, err)
+				return
+			}
+			onReady([]Provider{}, key, salt)
+		})
+		return
+	}
+	if err != nil {
+		showStartupError(app, fmt.Errorf("failed to read %s: %v", filePath, err))
+		return
+	}
+
+	if isEncryptedStorage(data) {
+		showUnlockModal(app, data, onReady)
+		return
+	}
+
+	// Legacy cleartext providers.json: parse it directly, then require a
+	// passphrase and re-encrypt it in place.
+	var providers []Provider
+	if err := json.Unmarshal(data, &providers); err != nil {
+		showStartupError(app, fmt.Errorf("failed to parse %s: %v", filePath, err))
+		return
+	}
+	showSetPassphraseModal(app, "This version encrypts providers.json. Set a passphrase to migrate your existing providers.", func(passphrase string) {
+		salt, err := newSalt()
+		if err != nil {
+			showStartupError(app, err)
+			return
+		}
+		key := deriveKey(passphrase, salt)
+		if err := persistEncrypted(providers, key, salt, filePath); err != nil {
+			zeroize(key)
+			showStartupError(app, err)
+			return
+		}
+		onReady(providers, key, salt)
+	})
+}
+
+// persistEncrypted seals providers under key/salt and writes it to
+// filePath with owner-only permissions.
+func persistEncrypted(providers []Provider, key, salt []byte, filePath string) error {
+	if providers == nil {
+		providers = []Provider{}
+	}
+	data, err := sealProviders(providers, key, salt)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0600)
+}
+
+// showUnlockModal prompts for the passphrase to an existing encrypted
+// file, retrying on a wrong passphrase, and calls onReady once decrypted.
+func showUnlockModal(app *tview.Application, data []byte, onReady func(providers []Provider, key, salt []byte)) {
+	status := tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignCenter)
+
+	passphraseField := tview.NewInputField().
+		SetLabel("Passphrase").
+		SetFieldWidth(40).
+		SetMaskCharacter('*')
+
+	form := tview.NewForm().
+		AddFormItem(passphraseField)
+	form.AddButton("Unlock", func() {
+		providers, key, salt, err := openProviders(data, passphraseField.GetText())
+		if err != nil {
+			status.SetText(fmt.Sprintf("[red]%s[white]", err.Error()))
+			passphraseField.SetText("")
+			return
+		}
+		onReady(providers, key, salt)
+	})
+	form.AddButton("Quit", func() {
+		app.Stop()
+	})
+	form.SetBorder(true).SetTitle(" Unlock providers.json ")
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 1, 0, false)
+
+	app.SetRoot(layout, true).SetFocus(form)
+}
+
+// showSetPassphraseModal prompts twice for a new passphrase (to catch
+// typos) and calls onSet once both entries match and are non-empty.
+func showSetPassphraseModal(app *tview.Application, message string, onSet func(passphrase string)) {
+	status := tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignCenter).SetText(message)
+
+	passphraseField := tview.NewInputField().
+		SetLabel("New passphrase").
+		SetFieldWidth(40).
+		SetMaskCharacter('*')
+	confirmField := tview.NewInputField().
+		SetLabel("Confirm passphrase").
+		SetFieldWidth(40).
+		SetMaskCharacter('*')
+
+	form := tview.NewForm().
+		AddFormItem(passphraseField).
+		AddFormItem(confirmField)
+	form.AddButton("Set passphrase", func() {
+		passphrase := passphraseField.GetText()
+		if passphrase == "" {
+			status.SetText("[red]Passphrase cannot be empty[white]")
+			return
+		}
+		if passphrase != confirmField.GetText() {
+			status.SetText("[red]Passphrases do not match[white]")
+			passphraseField.SetText("")
+			confirmField.SetText("")
+			return
+		}
+		onSet(passphrase)
+	})
+	form.SetBorder(true).SetTitle(" Set Passphrase ")
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 2, 0, false)
+
+	app.SetRoot(layout, true).SetFocus(form)
+}
+
+// showChangePassphraseModal lets the user replace the passphrase
+// protecting providers.json: it verifies the current one, derives a new
+// key/salt pair, re-encrypts providers under it, and reports the new
+// key/salt to onChanged so the caller can start using it. onBack is called
+// to return to the caller's screen, on both Change and Cancel.
+func showChangePassphraseModal(app *tview.Application, providers []Provider, filePath string, currentKey, currentSalt []byte, onChanged func(newKey, newSalt []byte), onBack func()) {
+	status := tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignCenter)
+
+	oldField := tview.NewInputField().SetLabel("Current passphrase").SetFieldWidth(40).SetMaskCharacter('*')
+	newField := tview.NewInputField().SetLabel("New passphrase").SetFieldWidth(40).SetMaskCharacter('*')
+	confirmField := tview.NewInputField().SetLabel("Confirm new passphrase").SetFieldWidth(40).SetMaskCharacter('*')
+
+	form := tview.NewForm().
+		AddFormItem(oldField).
+		AddFormItem(newField).
+		AddFormItem(confirmField)
+	form.AddButton("Change", func() {
+		if subtle.ConstantTimeCompare(deriveKey(oldField.GetText(), currentSalt), currentKey) != 1 {
+			status.SetText("[red]Current passphrase is incorrect[white]")
+			return
+		}
+		newPassphrase := newField.GetText()
+		if newPassphrase == "" {
+			status.SetText("[red]New passphrase cannot be empty[white]")
+			return
+		}
+		if newPassphrase != confirmField.GetText() {
+			status.SetText("[red]New passphrases do not match[white]")
+			return
+		}
+
+		salt, err := newSalt()
+		if err != nil {
+			status.SetText(fmt.Sprintf("[red]%s[white]", err.Error()))
+			return
+		}
+		key := deriveKey(newPassphrase, salt)
+		if err := persistEncrypted(providers, key, salt, filePath); err != nil {
+			zeroize(key)
+			status.SetText(fmt.Sprintf("[red]%s[white]", err.Error()))
+			return
+		}
+
+		onChanged(key, salt)
+		onBack()
+	})
+	form.AddButton("Cancel", func() {
+		onBack()
+	})
+	form.SetBorder(true).SetTitle(" Change Passphrase ")
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 1, 0, false)
+
+	app.SetRoot(layout, true).SetFocus(form)
+}
+
+// showStartupError shows a fatal startup error with no way forward but to
+// quit, since the app has no usable provider state at this point.
+func showStartupError(app *tview.Application, err error) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Error: %s", err.Error())).
+		AddButtons([]string{"Quit"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			app.Stop()
+		})
+	app.SetRoot(modal, true)
+}
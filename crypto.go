@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Storage format constants
+const (
+	storageVersion = 1
+	kdfArgon2id    = "argon2id"
+
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024 // KiB, i.e. 64 MiB
+	argon2Threads = 4
+	argon2KeyLen  = 32 // AES-256
+
+	saltSize = 16
+)
+
+// encryptedFile is the on-disk, versioned format shared by providers.json
+// and .otpbak backup archives: {version, kdf, salt, nonce, ciphertext}.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       string `json:"salt"`       // base64
+	Nonce      string `json:"nonce"`      // base64
+	Ciphertext string `json:"ciphertext"` // base64
+}
+
+// isEncryptedStorage reports whether data is the versioned encryptedFile
+// format rather than a legacy cleartext JSON array of Provider.
+func isEncryptedStorage(data []byte) bool {
+	var probe struct {
+		Version int `json:"version"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.Version > 0
+}
+
+// newSalt generates a fresh random salt for Argon2id key derivation.
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	return salt, nil
+}
+
+// deriveKey derives a 32-byte AES-256 key from a passphrase and salt using
+// Argon2id.
+func deriveKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+}
+
+// zeroize overwrites key in place so the derived key doesn't linger in
+// memory longer than necessary.
+func zeroize(key []byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
+
+// sealBytes encrypts plaintext with AES-256-GCM under key, writing salt
+// into the header so that the same passphrase always re-derives the same
+// key on the next openBytes.
+func sealBytes(plaintext, key, salt []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AEAD: %v", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	file := encryptedFile{
+		Version:    storageVersion,
+		KDF:        kdfArgon2id,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	}
+
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// openBytes decrypts data (the encryptedFile format) using a key derived
+// from passphrase, returning the plaintext along with the derived key and
+// salt so the caller can cache them for subsequent sealBytes calls without
+// re-running the (deliberately expensive) KDF.
+func openBytes(data []byte, passphrase string) (plaintext, key, salt []byte, err error) {
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse encrypted file: %v", err)
+	}
+	if file.KDF != kdfArgon2id {
+		return nil, nil, nil, fmt.Errorf("unsupported key derivation function: %s", file.KDF)
+	}
+
+	salt, err = base64.StdEncoding.DecodeString(file.Salt)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid salt: %v", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid nonce: %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(file.Ciphertext)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid ciphertext: %v", err)
+	}
+
+	key = deriveKey(passphrase, salt)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		zeroize(key)
+		return nil, nil, nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		zeroize(key)
+		return nil, nil, nil, fmt.Errorf("failed to create AEAD: %v", err)
+	}
+
+	plaintext, err = gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		zeroize(key)
+		return nil, nil, nil, fmt.Errorf("incorrect passphrase or corrupted file")
+	}
+
+	return plaintext, key, salt, nil
+}
+
+// sealProviders serializes providers to JSON and encrypts it under key/salt.
+func sealProviders(providers []Provider, key, salt []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(providers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal providers: %v", err)
+	}
+	return sealBytes(plaintext, key, salt)
+}
+
+// openProviders decrypts data and unmarshals it into a provider list.
+func openProviders(data []byte, passphrase string) (providers []Provider, key, salt []byte, err error) {
+	plaintext, key, salt, err := openBytes(data, passphrase)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := json.Unmarshal(plaintext, &providers); err != nil {
+		zeroize(key)
+		return nil, nil, nil, fmt.Errorf("failed to unmarshal providers: %v", err)
+	}
+
+	return providers, key, salt, nil
+}
@@ -12,6 +12,30 @@ import (
 	"github.com/rivo/tview"
 )
 
+// FilePickerMode selects whether ShowFilePicker is choosing a file to open
+// or a directory to use as a destination.
+type FilePickerMode int
+
+const (
+	FilePickerModeFile FilePickerMode = iota
+	FilePickerModeDirectory
+)
+
+// FilePickerOptions configures ShowFilePicker's filtering and behavior.
+type FilePickerOptions struct {
+	// AllowedExts restricts which files can be picked, e.g.
+	// []string{".png", ".jpg", ".txt"}. Files with another extension are
+	// still shown so the user can see they exist, but can't be selected.
+	// They are not dimmed; their entry carries a " (not allowed)" suffix
+	// instead. An empty slice allows any file.
+	AllowedExts []string
+	// ShowHidden includes dotfiles in the listing. Can be toggled at
+	// runtime with Ctrl-H.
+	ShowHidden bool
+	// StartMode picks a file (the default) or a directory.
+	StartMode FilePickerMode
+}
+
 // FileInfo represents a file or directory in the file picker
 type FileInfo struct {
 	Name     string
@@ -20,8 +44,10 @@ type FileInfo struct {
 	IsParent bool
 }
 
-// listDirectory returns a list of files and directories in the given directory
-func listDirectory(dirPath string) ([]FileInfo, error) {
+// listDirectory returns a list of files and directories in the given
+// directory. Hidden files (dotfiles) are included only if showHidden is
+// true.
+func listDirectory(dirPath string, showHidden bool) ([]FileInfo, error) {
 	// If dirPath is empty, use the current directory
 	if dirPath == "" {
 		var err error
@@ -55,8 +81,7 @@ func listDirectory(dirPath string) ([]FileInfo, error) {
 
 	// Add files and directories to the result
 	for _, file := range files {
-		// Skip hidden files
-		if strings.HasPrefix(file.Name(), ".") {
+		if !showHidden && strings.HasPrefix(file.Name(), ".") {
 			continue
 		}
 
@@ -90,130 +115,260 @@ func listDirectory(dirPath string) ([]FileInfo, error) {
 	return result, nil
 }
 
-// ShowFilePicker displays a file picker dialog and returns the selected file path
-func ShowFilePicker(app *tview.Application, currentPath string, callback func(string)) {
-	// Store the original root primitive and input capture function
-	originalRoot := app.GetFocus()
+// matchesExtFilter reports whether name passes opts.AllowedExts. An empty
+// filter allows everything.
+func matchesExtFilter(name string, allowedExts []string) bool {
+	if len(allowedExts) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range allowedExts {
+		if strings.ToLower(allowed) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// selectDirectoryLabel is the synthetic list entry that lets the user pick
+// the directory they're currently browsing, rather than navigating into it.
+const selectDirectoryLabel = "📂 [Use this directory]"
+
+// ShowFilePicker displays a file picker dialog and reports the selected
+// path to callback. It takes over app's root and input capture until a
+// selection is made or the user cancels, then restores previousRoot (the
+// screen the caller was showing beforehand) rather than guessing at it.
+func ShowFilePicker(app *tview.Application, currentPath string, opts FilePickerOptions, previousRoot tview.Primitive, callback func(string)) {
+	// Store the original input capture function; the root to restore to is
+	// given to us, since app.GetFocus() at call time is just the button that
+	// opened the picker, not the screen it lives on.
 	originalInputCapture := app.GetInputCapture()
 
 	// Function to restore the original application state
 	restoreOriginal := func() {
-		app.SetRoot(originalRoot, true)
+		app.SetRoot(previousRoot, true)
 		app.SetInputCapture(originalInputCapture)
 	}
 
-	// Create a modal for the file picker
-	modal := tview.NewModal()
-	modal.SetBorder(true).SetTitle(" File Picker ")
+	showHidden := opts.ShowHidden
+	var filterQuery string
+	var typeAheadBuffer string
+	var allFiles []FileInfo // unfiltered listing of the current directory
+
+	// Status/help line, doubling up as an error line when a filtered-out
+	// file is picked.
+	statusView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignCenter)
+
+	filterField := tview.NewInputField().
+		SetLabel("Filter: ").
+		SetFieldWidth(0)
 
 	// Create a list for files and directories
 	fileList := tview.NewList().
 		ShowSecondaryText(false).
 		SetHighlightFullLine(true)
 
-	// Function to update the file list with the contents of the current directory
 	var updateFileList func(string)
+	var renderFiltered func()
 
 	// Function to handle file selection
 	handleSelection := func(index int) {
-		// Get the selected file info
-		mainText, secondaryText := fileList.GetItemText(index)
-		path := secondaryText
-		isDir := strings.HasPrefix(mainText, "📁")
-
-		if isDir {
-			// If it's a directory, navigate into it
-			updateFileList(path)
-		} else {
-			// If it's a file, return the path and close the modal
+		if index < 0 || index >= fileList.GetItemCount() {
+			return
+		}
+		_, path := fileList.GetItemText(index)
+
+		if opts.StartMode == FilePickerModeDirectory && path == currentPath {
+			// The synthetic "use this directory" entry
+			callback(currentPath)
+			restoreOriginal()
+			return
+		}
+
+		for _, file := range allFiles {
+			if file.Path != path {
+				continue
+			}
+
+			if file.IsDir {
+				typeAheadBuffer = ""
+				updateFileList(path)
+				return
+			}
+
+			if opts.StartMode == FilePickerModeDirectory {
+				// Files can't be picked in directory mode
+				statusView.SetText(fmt.Sprintf("[yellow]%s[white] is a file; navigate into a directory or select it with Enter on \"%s\"", file.Name, selectDirectoryLabel))
+				return
+			}
+
+			if !matchesExtFilter(file.Name, opts.AllowedExts) {
+				statusView.SetText(fmt.Sprintf("[red]%s is not one of the allowed file types[white]", file.Name))
+				return
+			}
+
 			callback(path)
-			// Restore the original application state
 			restoreOriginal()
+			return
 		}
 	}
 
-	updateFileList = func(dirPath string) {
-		// Clear the list
+	renderFiltered = func() {
 		fileList.Clear()
 
-		// Get files and directories
-		files, err := listDirectory(dirPath)
-		if err != nil {
-			modal.SetText(fmt.Sprintf("Error: %s", err.Error())).
-				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-					// Restore the original application state
-					restoreOriginal()
-				})
-			return
+		if opts.StartMode == FilePickerModeDirectory {
+			fileList.AddItem(selectDirectoryLabel, currentPath, 0, nil)
 		}
 
-		// Update the current path
-		currentPath = dirPath
+		query := strings.ToLower(filterQuery)
+		for _, file := range allFiles {
+			if query != "" && !file.IsParent && !strings.Contains(strings.ToLower(file.Name), query) {
+				continue
+			}
 
-		// Add files and directories to the list
-		for i, file := range files {
-			// Display icon based on type
 			var prefix string
-			if file.IsParent {
-				prefix = "📁 "
-			} else if file.IsDir {
+			if file.IsDir {
 				prefix = "📁 "
 			} else {
 				prefix = "📄 "
 			}
 
-			// Add item to the list
-			fileList.AddItem(prefix+file.Name, file.Path, rune('a'+i%26), nil)
+			mainText := prefix + file.Name
+			if !file.IsDir && !matchesExtFilter(file.Name, opts.AllowedExts) {
+				// tview.List renders main text verbatim (no dynamic-color tag
+				// parsing like TextView), so "dimming" is a plain-text marker
+				// rather than a color tag.
+				mainText += " (not allowed)"
+			}
+
+			fileList.AddItem(mainText, file.Path, 0, nil)
 		}
 
-		// Update the modal title to show current directory
-		modal.SetTitle(fmt.Sprintf(" File Picker - %s ", currentPath))
+		title := fmt.Sprintf(" File Picker - %s ", currentPath)
+		if query != "" {
+			title = fmt.Sprintf(" File Picker - %s (filter: %s) ", currentPath, filterQuery)
+		}
+		fileList.SetTitle(title).SetBorder(true)
+	}
+
+	updateFileList = func(dirPath string) {
+		files, err := listDirectory(dirPath, showHidden)
+		if err != nil {
+			statusView.SetText(fmt.Sprintf("[red]Error: %s[white]", err.Error()))
+			return
+		}
+
+		currentPath = dirPath
+		allFiles = files
+		filterQuery = ""
+		renderFiltered()
 	}
 
 	// Set up the file list selection handler
 	fileList.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
-		handleSelection(fileList.GetCurrentItem())
+		handleSelection(index)
 	})
 
+	helpText := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignCenter).
+		SetText("[yellow]↑↓[white]: Navigate  [yellow]Enter[white]: Select  [yellow]Ctrl-H[white]: Toggle hidden files  " +
+			"[yellow]/[white]: Filter  [yellow]Esc[white]: Cancel  [yellow]type[white]: jump to entry")
+
 	// Create a flex layout for the file picker
 	flex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(fileList, 0, 1, true).
-		AddItem(tview.NewTextView().
-			SetTextAlign(tview.AlignCenter).
-			SetText("[yellow]↑↓[white]: Navigate  [yellow]Enter[white]: Select  [yellow]Esc[white]: Cancel"), 1, 0, false)
-
-	// Set up the modal
-	modal.
-		SetText("Select a file").
-		AddButtons([]string{"Cancel"}).
-		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			// Restore the original application state
-			restoreOriginal()
-		})
+		AddItem(statusView, 1, 0, false).
+		AddItem(helpText, 1, 0, false)
 
-	// Create a pages component to hold the flex layout
-	pages := tview.NewPages().
-		AddPage("picker", flex, true, true)
+	filterFlex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(filterField, 1, 0, true).
+		AddItem(fileList, 0, 1, false).
+		AddItem(statusView, 1, 0, false).
+		AddItem(helpText, 1, 0, false)
+
+	enterFilterMode := func() {
+		filterField.SetText("")
+		app.SetRoot(filterFlex, true).SetFocus(filterField)
+	}
+	exitFilterMode := func() {
+		renderFiltered()
+		app.SetRoot(flex, true).SetFocus(fileList)
+	}
+
+	filterField.SetChangedFunc(func(text string) {
+		filterQuery = text
+		renderFiltered()
+	})
+	filterField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			filterQuery = ""
+		}
+		exitFilterMode()
+	})
+
+	// type-to-jump: jump the selection to the first entry whose name starts
+	// with the accumulated buffer. Every typed character except '/' is added
+	// to the buffer; it is reset on Enter, Ctrl-H, the Up/Down keys and on
+	// entering a directory.
+	jumpToTypeAheadMatch := func() {
+		if typeAheadBuffer == "" {
+			return
+		}
+		prefix := strings.ToLower(typeAheadBuffer)
+		for i := 0; i < fileList.GetItemCount(); i++ {
+			mainText, _ := fileList.GetItemText(i)
+			name := strings.TrimPrefix(strings.TrimPrefix(mainText, "📁 "), "📄 ")
+			name = strings.TrimSuffix(name, " (not allowed)")
+			if strings.HasPrefix(strings.ToLower(name), prefix) {
+				fileList.SetCurrentItem(i)
+				return
+			}
+		}
+	}
 
 	// Set up key handling for the file list
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if app.GetFocus() == filterField {
+			// Let the filter input field handle its own keystrokes
+			return event
+		}
+
 		switch event.Key() {
 		case tcell.KeyEscape:
-			// Restore the original application state
 			restoreOriginal()
 			return nil
 		case tcell.KeyEnter:
-			// Handle Enter key to select the current item
+			typeAheadBuffer = ""
 			handleSelection(fileList.GetCurrentItem())
 			return nil
+		case tcell.KeyCtrlH:
+			showHidden = !showHidden
+			typeAheadBuffer = ""
+			updateFileList(currentPath)
+			return nil
+		case tcell.KeyUp, tcell.KeyDown:
+			typeAheadBuffer = ""
+			return event
+		case tcell.KeyRune:
+			if event.Rune() == '/' {
+				enterFilterMode()
+				return nil
+			}
+			typeAheadBuffer += string(event.Rune())
+			jumpToTypeAheadMatch()
+			return nil
 		}
 		return event
 	})
 
-	// Initial update of the file list
+	// Initial listing of the current directory
 	updateFileList(currentPath)
 
 	// Show the file picker
-	app.SetRoot(pages, true)
+	app.SetRoot(flex, true).SetFocus(fileList)
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// backupMeta is written alongside providers.json inside a .otpbak archive,
+// mostly so a human inspecting an extracted archive knows what it is.
+type backupMeta struct {
+	Version       int `json:"version"`
+	ProviderCount int `json:"provider_count"`
+}
+
+// buildBackupArchive tars up providers.json and meta.json; the archive
+// itself is encrypted by the caller, so its contents are plain JSON.
+func buildBackupArchive(providers []Provider) ([]byte, error) {
+	providersJSON, err := json.MarshalIndent(providers, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal providers: %v", err)
+	}
+
+	metaJSON, err := json.MarshalIndent(backupMeta{
+		Version:       storageVersion,
+		ProviderCount: len(providers),
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup metadata: %v", err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{"providers.json", providersJSON},
+		{"meta.json", metaJSON},
+	} {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: file.name,
+			Mode: 0600,
+			Size: int64(len(file.data)),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write %s header: %v", file.name, err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", file.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseBackupArchive extracts providers.json out of a tar archive built by
+// buildBackupArchive.
+func parseBackupArchive(archive []byte) ([]Provider, error) {
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %v", err)
+		}
+		if hdr.Name != "providers.json" {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read providers.json from archive: %v", err)
+		}
+
+		var providers []Provider
+		if err := json.Unmarshal(data, &providers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal providers.json from archive: %v", err)
+		}
+		return providers, nil
+	}
+
+	return nil, fmt.Errorf("archive did not contain providers.json")
+}
+
+// exportBackup writes providers to outPath as a .otpbak archive, encrypted
+// with the same AEAD scheme (and key/salt) that protects providers.json.
+func exportBackup(providers []Provider, key, salt []byte, outPath string) error {
+	archive, err := buildBackupArchive(providers)
+	if err != nil {
+		return err
+	}
+
+	data, err := sealBytes(archive, key, salt)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(outPath, data, 0600)
+}
+
+// importBackup decrypts a .otpbak archive at path with passphrase and
+// returns the providers it contains.
+func importBackup(path, passphrase string) ([]Provider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup file: %v", err)
+	}
+
+	archive, _, _, err := openBytes(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBackupArchive(archive)
+}
+
+// parsePayloadToProviders converts a decoded otpauth-migration:// or
+// otpauth:// payload (straight from a file, pasted text, or a QR code)
+// into the Providers it describes.
+func parsePayloadToProviders(payload string) ([]Provider, error) {
+	switch {
+	case strings.HasPrefix(payload, "otpauth-migration://"):
+		return migrationURIToProviders(payload)
+	case strings.HasPrefix(payload, "otpauth://"):
+		provider, err := ParseOTPAuthURI(payload)
+		if err != nil {
+			return nil, err
+		}
+		return []Provider{provider}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized import payload")
+	}
+}
+
+// importFromFile reads providers out of path, dispatching on its
+// extension much like readSecretFromFile does for single-secret imports:
+// a .otpbak archive needs passphrase to decrypt, while .txt files and QR
+// code images are expected to carry an otpauth-migration:// or otpauth://
+// payload directly.
+func importFromFile(path, passphrase string) ([]Provider, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	switch ext {
+	case ".otpbak":
+		return importBackup(path, passphrase)
+	case ".txt":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %v", err)
+		}
+		return parsePayloadToProviders(strings.TrimSpace(string(data)))
+	case ".png", ".jpg", ".jpeg", ".gif":
+		payload, err := decodeQRCodeFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read QR code: %v", err)
+		}
+		return parsePayloadToProviders(strings.TrimSpace(payload))
+	default:
+		return nil, fmt.Errorf("unsupported import file type: %s", ext)
+	}
+}
+
+// showImportExportScreen replaces the main UI with a screen for bulk
+// importing providers (Google Authenticator migration payloads, .otpbak
+// archives) and exporting the current providers to a .otpbak archive.
+// onImported is called with the providers to merge in once an import
+// succeeds; onBack is called by the Back button to return to the caller's
+// screen. Export results are reported on this screen's own status line.
+func showImportExportScreen(app *tview.Application, providers []Provider, sessionKey, sessionSalt []byte, onImported func(imported []Provider), onBack func()) {
+	// currentProviders tracks this screen's own view of the provider list so
+	// "Export all..." includes anything imported earlier in the same visit,
+	// rather than the stale snapshot the caller passed in at open time.
+	currentProviders := providers
+
+	// layout is declared up front so the Browse/Export buttons below can
+	// pass it to ShowFilePicker as the screen to return to; it's populated
+	// once the rest of the screen has been built, the same way main.go
+	// declares mainFlex ahead of its own Browse button.
+	var layout *tview.Flex
+
+	status := tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignCenter)
+
+	importPathField := tview.NewInputField().SetLabel("Import File Path").SetFieldWidth(40)
+	backupPassphraseField := tview.NewInputField().SetLabel("Backup Passphrase").SetFieldWidth(40).SetMaskCharacter('*')
+	exportPathField := tview.NewInputField().SetLabel("Export File Path").SetFieldWidth(40).SetText("backup.otpbak")
+
+	form := tview.NewForm()
+	form.
+		AddFormItem(importPathField).
+		AddFormItem(backupPassphraseField).
+		AddButton("Browse for import...", func() {
+			currentPath, err := os.Getwd()
+			if err != nil {
+				status.SetText(fmt.Sprintf("[red]%s[white]", err.Error()))
+				return
+			}
+			opts := FilePickerOptions{
+				AllowedExts: []string{".png", ".jpg", ".jpeg", ".gif", ".txt", ".otpbak"},
+				StartMode:   FilePickerModeFile,
+			}
+			ShowFilePicker(app, currentPath, opts, layout, func(selectedPath string) {
+				importPathField.SetText(selectedPath)
+			})
+		}).
+		AddButton("Import", func() {
+			path := importPathField.GetText()
+			if path == "" {
+				status.SetText("[red]Import file path cannot be empty[white]")
+				return
+			}
+
+			imported, err := importFromFile(path, backupPassphraseField.GetText())
+			if err != nil {
+				status.SetText(fmt.Sprintf("[red]%s[white]", err.Error()))
+				return
+			}
+
+			currentProviders = append(currentProviders, imported...)
+			onImported(imported)
+			status.SetText(fmt.Sprintf("[green]Imported %d provider(s) from %s[white]", len(imported), path))
+		}).
+		AddFormItem(exportPathField).
+		AddButton("Browse for export...", func() {
+			currentPath, err := os.Getwd()
+			if err != nil {
+				status.SetText(fmt.Sprintf("[red]%s[white]", err.Error()))
+				return
+			}
+			opts := FilePickerOptions{StartMode: FilePickerModeDirectory}
+			ShowFilePicker(app, currentPath, opts, layout, func(selectedDir string) {
+				exportPathField.SetText(filepath.Join(selectedDir, filepath.Base(exportPathField.GetText())))
+			})
+		}).
+		AddButton("Export all...", func() {
+			path := exportPathField.GetText()
+			if path == "" {
+				status.SetText("[red]Export file path cannot be empty[white]")
+				return
+			}
+
+			if err := exportBackup(currentProviders, sessionKey, sessionSalt, path); err != nil {
+				status.SetText(fmt.Sprintf("[red]%s[white]", err.Error()))
+				return
+			}
+
+			status.SetText(fmt.Sprintf("[green]Exported %d provider(s) to %s[white]", len(currentProviders), path))
+		}).
+		AddButton("Back", func() {
+			onBack()
+		})
+	form.SetBorder(true).SetTitle(" Import / Export ")
+
+	layout = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 1, 0, false)
+
+	app.SetRoot(layout, true).SetFocus(form)
+}
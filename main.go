@@ -2,14 +2,12 @@ package main
 
 import (
 	"encoding/base32"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/pquerna/otp/totp"
 	"github.com/rivo/tview"
 )
 
@@ -21,108 +19,117 @@ const (
 
 // Provider represents an OTP provider/account
 type Provider struct {
-	Name   string
-	Secret string
+	Name      string
+	Secret    string
+	Issuer    string
+	Algorithm string       // SHA1, SHA256 or SHA512
+	Digits    int          // 6 or 8
+	Period    int          // seconds, TOTP only
+	Type      ProviderType // TOTP or HOTP
+	Counter   uint64       // HOTP only
 }
 
-// generateTOTP creates a time-based one-time password
-func generateTOTP(secret string) (string, error) {
-	// Generate a TOTP code using the provided secret
-	code, err := totp.GenerateCode(secret, time.Now())
-	if err != nil {
-		return "", err
+// defaultProvider fills in a Provider with the Key URI Format defaults for
+// a plain base32 secret that carries no other metadata.
+func defaultProvider(secret string) Provider {
+	return Provider{
+		Secret:    secret,
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+		Type:      ProviderTypeTOTP,
 	}
-	return code, nil
 }
 
-// readSecretFromFile reads a secret from a file
-// It can handle both plain text files and QR code images
-func readSecretFromFile(filePath string) (string, error) {
+// readSecretFromFile reads a Provider from a file.
+// It can handle plain text/key files, QR code images, and files or QR
+// codes whose payload is an otpauth:// URI.
+func readSecretFromFile(filePath string) (Provider, error) {
 	// Get the file extension
 	ext := strings.ToLower(filepath.Ext(filePath))
 
-	// Read the file
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read file: %v", err)
-	}
+	var payload string
 
-	// If it's a text file, assume it contains the secret directly
-	if ext == ".txt" || ext == ".key" {
-		// Trim any whitespace
-		secret := strings.TrimSpace(string(data))
+	switch ext {
+	case ".txt", ".key":
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return Provider{}, fmt.Errorf("failed to read file: %v", err)
+		}
+		payload = strings.TrimSpace(string(data))
+	case ".png", ".jpg", ".jpeg", ".gif":
+		decoded, err := decodeQRCodeFromFile(filePath)
+		if err != nil {
+			return Provider{}, fmt.Errorf("failed to read QR code: %v", err)
+		}
+		payload = strings.TrimSpace(decoded)
+	default:
+		return Provider{}, fmt.Errorf("unsupported file type: %s", ext)
+	}
 
-		// Validate that the secret is a valid base32 string
-		_, err := base32.StdEncoding.DecodeString(secret)
+	// The payload may be a full otpauth:// URI or a bare base32 secret.
+	if strings.HasPrefix(payload, "otpauth://") {
+		provider, err := ParseOTPAuthURI(payload)
 		if err != nil {
-			return "", fmt.Errorf("invalid base32 string in file: %v", err)
+			return Provider{}, fmt.Errorf("failed to parse otpauth URI: %v", err)
 		}
+		return provider, nil
+	}
 
-		return secret, nil
+	if _, err := base32.StdEncoding.DecodeString(payload); err != nil {
+		return Provider{}, fmt.Errorf("file did not contain a valid base32 secret or otpauth URI: %v", err)
 	}
 
-	// For other file types, try to parse as a QR code
-	// In a real implementation, you would use a QR code parsing library
-	// For now, we'll just return an error message
-	return "", fmt.Errorf("QR code parsing is not supported in this version")
+	return defaultProvider(payload), nil
 }
 
-// saveProviders saves the providers to a JSON file
-func saveProviders(providers []Provider, filePath string) error {
+// saveProviders encrypts providers under key/salt and saves them to
+// filePath. key/salt are the ones produced by the startup passphrase
+// unlock/migration flow in auth.go.
+func saveProviders(providers []Provider, filePath string, key, salt []byte) error {
 	// Create the directory if it doesn't exist
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %v", err)
 	}
 
-	// Marshal the providers to JSON
-	data, err := json.MarshalIndent(providers, "", "  ")
+	data, err := sealProviders(providers, key, salt)
 	if err != nil {
-		return fmt.Errorf("failed to marshal providers: %v", err)
+		return err
 	}
 
-	// Write the JSON to the file
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	// Write the encrypted file, owner-readable only
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
 
 	return nil
 }
 
-// loadProviders loads the providers from a JSON file
-func loadProviders(filePath string) ([]Provider, error) {
-	// Check if the file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// File doesn't exist, return an empty slice
-		return []Provider{}, nil
-	}
+func main() {
+	// Create a new application
+	app := tview.NewApplication()
 
-	// Read the file
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %v", err)
-	}
+	// Unlock (or create, or migrate) providers.json before showing the
+	// main UI; runApp is invoked once a passphrase has been established.
+	runStartupFlow(app, ProvidersFilePath, func(providers []Provider, key, salt []byte) {
+		runApp(app, providers, key, salt)
+	})
 
-	// Unmarshal the JSON
-	var providers []Provider
-	if err := json.Unmarshal(data, &providers); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal providers: %v", err)
+	if err := app.Run(); err != nil {
+		panic(err)
 	}
-
-	return providers, nil
 }
 
-func main() {
-	// Create a new application
-	app := tview.NewApplication()
+// runApp builds and shows the main OTP Auth UI once providers have been
+// decrypted and the AES key/salt protecting them are available.
+func runApp(app *tview.Application, providers []Provider, sessionKey, sessionSalt []byte) {
 
-	// Load providers from file
-	providers, err := loadProviders(ProvidersFilePath)
-	if err != nil {
-		// If there's an error loading providers, log it and start with an empty slice
-		fmt.Printf("Error loading providers: %v\n", err)
-		providers = []Provider{}
-	}
+	// mainFlex is declared up front so screens it navigates away to (the
+	// file picker, the passphrase modal, the Import/Export screen) can
+	// restore it by reference rather than guessing at the previous root;
+	// it's populated once the rest of the UI below has been built.
+	var mainFlex *tview.Flex
 
 	// Track the currently selected provider
 	selectedProviderIndex := -1 // -1 indicates no provider is selected
@@ -142,6 +149,8 @@ func main() {
 	// Variables to store current OTP information
 	var currentOTP string
 	var currentProvider string
+	var currentProviderType ProviderType
+	var currentProviderPeriod int
 
 	// Function to update only the countdown display
 	updateCountdown := func() {
@@ -149,11 +158,21 @@ func main() {
 			return
 		}
 
-		// Calculate remaining seconds
-		remainingSeconds := 30 - time.Now().Second()%30
 		// Escape any square brackets in the provider name and OTP
 		escapedProvider := strings.ReplaceAll(strings.ReplaceAll(currentProvider, "[", "[["), "]", "]]")
 		escapedOTP := strings.ReplaceAll(strings.ReplaceAll(currentOTP, "[", "[["), "]", "]]")
+
+		if currentProviderType == ProviderTypeHOTP {
+			otpView.SetText(fmt.Sprintf("[green]Provider: [yellow]%s[white]\n[green]Your OTP: [yellow]%s[white]\n\n[red]HOTP[white]: select the provider again to generate the next code", escapedProvider, escapedOTP))
+			return
+		}
+
+		// Calculate remaining seconds in the provider's own period
+		period := currentProviderPeriod
+		if period <= 0 {
+			period = DefaultPeriod
+		}
+		remainingSeconds := period - int(time.Now().Unix())%period
 		otpView.SetText(fmt.Sprintf("[green]Provider: [yellow]%s[white]\n[green]Your OTP: [yellow]%s[white]\n\nValid for [red]%d[white] seconds", escapedProvider, escapedOTP, remainingSeconds))
 	}
 
@@ -173,10 +192,10 @@ func main() {
 		}
 
 		// Get the selected provider
-		provider := providers[selectedProviderIndex]
+		provider := &providers[selectedProviderIndex]
 
 		// Generate a new OTP
-		otp, err := generateTOTP(provider.Secret)
+		otp, err := generateTOTP(provider)
 		if err != nil {
 			// Escape any square brackets in the error message
 			escapedError := strings.ReplaceAll(strings.ReplaceAll(err.Error(), "[", "[["), "]", "]]")
@@ -186,9 +205,20 @@ func main() {
 			return
 		}
 
+		// HOTP generation advances the counter, so persist it immediately
+		if provider.Type == ProviderTypeHOTP {
+			if err := saveProviders(providers, ProvidersFilePath, sessionKey, sessionSalt); err != nil {
+				escapedError := strings.ReplaceAll(strings.ReplaceAll(err.Error(), "[", "[["), "]", "]]")
+				otpView.SetText(fmt.Sprintf("[red]Error saving counter: %s[white]", escapedError))
+				return
+			}
+		}
+
 		// Store current OTP information
 		currentOTP = otp
 		currentProvider = provider.Name
+		currentProviderType = provider.Type
+		currentProviderPeriod = provider.Period
 
 		// Update the display with countdown
 		updateCountdown()
@@ -208,12 +238,39 @@ func main() {
 		providerList.AddItem(provider.Name, "", rune('1'+i), nil)
 	}
 
+	// Holds the extra fields (Issuer/Algorithm/Digits/Period/Type/Counter)
+	// parsed from an otpauth URI until "Add Provider" consumes them.
+	var pendingParsedProvider *Provider
+
 	// Create a form to add new providers
 	newProviderForm := tview.NewForm()
 	newProviderForm.
 		AddInputField("Provider Name", "", 20, nil, nil).
 		AddInputField("Secret", "", 40, nil, nil).
 		AddInputField("File Path", "", 40, nil, nil).
+		AddInputField("Otpauth URI", "", 40, nil, nil).
+		AddButton("Parse URI", func() {
+			// Get the otpauth URI from the form
+			uri := newProviderForm.GetFormItem(3).(*tview.InputField).GetText()
+			if uri == "" {
+				otpView.SetText("[red]Error: Otpauth URI cannot be empty[white]")
+				return
+			}
+
+			provider, err := ParseOTPAuthURI(uri)
+			if err != nil {
+				escapedError := strings.ReplaceAll(strings.ReplaceAll(err.Error(), "[", "[["), "]", "]]")
+				otpView.SetText(fmt.Sprintf("[red]Error: %s[white]", escapedError))
+				return
+			}
+
+			// Populate the visible fields and stash the rest for Add Provider
+			newProviderForm.GetFormItem(0).(*tview.InputField).SetText(provider.Name)
+			newProviderForm.GetFormItem(1).(*tview.InputField).SetText(provider.Secret)
+			pendingParsedProvider = &provider
+
+			otpView.SetText(fmt.Sprintf("[green]Parsed %s provider from otpauth URI: [yellow]%s[white]", provider.Type, provider.Name))
+		}).
 		AddButton("Browse...", func() {
 			// Get the current file path from the form
 			currentPath := newProviderForm.GetFormItem(2).(*tview.InputField).GetText()
@@ -231,7 +288,11 @@ func main() {
 			}
 
 			// Show the file picker
-			ShowFilePicker(app, currentPath, func(selectedPath string) {
+			filePickerOpts := FilePickerOptions{
+				AllowedExts: []string{".txt", ".key", ".png", ".jpg", ".jpeg", ".gif"},
+				StartMode:   FilePickerModeFile,
+			}
+			ShowFilePicker(app, currentPath, filePickerOpts, mainFlex, func(selectedPath string) {
 				// Set the selected path in the file path input field
 				newProviderForm.GetFormItem(2).(*tview.InputField).SetText(selectedPath)
 
@@ -248,8 +309,8 @@ func main() {
 				return
 			}
 
-			// Read the secret from the file
-			secret, err := readSecretFromFile(filePath)
+			// Read the provider from the file
+			provider, err := readSecretFromFile(filePath)
 			if err != nil {
 				// Escape any square brackets in the error message
 				escapedError := strings.ReplaceAll(strings.ReplaceAll(err.Error(), "[", "[["), "]", "]]")
@@ -257,8 +318,13 @@ func main() {
 				return
 			}
 
-			// Set the secret in the form
-			newProviderForm.GetFormItem(1).(*tview.InputField).SetText(secret)
+			// Set the secret (and name, if the file carried one) in the form
+			if provider.Name != "" {
+				newProviderForm.GetFormItem(0).(*tview.InputField).SetText(provider.Name)
+			}
+			newProviderForm.GetFormItem(1).(*tview.InputField).SetText(provider.Secret)
+			pendingParsedProvider = &provider
+
 			// Escape any square brackets in the file path
 			escapedFilePath := strings.ReplaceAll(strings.ReplaceAll(filePath, "[", "[["), "]", "]]")
 			otpView.SetText(fmt.Sprintf("[green]Secret read successfully from file: [yellow]%s[white]", escapedFilePath))
@@ -285,18 +351,24 @@ func main() {
 				return
 			}
 
-			// Create a new provider with the provided secret
-			newProvider := Provider{
-				Name:   providerName,
-				Secret: secret,
+			// Start from the metadata parsed from a file/URI, if any,
+			// otherwise fall back to a plain TOTP-SHA1-6-30 provider
+			var newProvider Provider
+			if pendingParsedProvider != nil {
+				newProvider = *pendingParsedProvider
+			} else {
+				newProvider = defaultProvider(secret)
 			}
+			newProvider.Name = providerName
+			newProvider.Secret = secret
+			pendingParsedProvider = nil
 
 			// Add the new provider to the list
 			providers = append(providers, newProvider)
 			providerList.AddItem(newProvider.Name, "", rune('1'+len(providers)-1), nil)
 
 			// Save providers to file
-			if err := saveProviders(providers, ProvidersFilePath); err != nil {
+			if err := saveProviders(providers, ProvidersFilePath, sessionKey, sessionSalt); err != nil {
 				// Escape any square brackets in the error message
 				escapedError := strings.ReplaceAll(strings.ReplaceAll(err.Error(), "[", "[["), "]", "]]")
 				otpView.SetText(fmt.Sprintf("[red]Error saving providers: %s[white]", escapedError))
@@ -309,6 +381,7 @@ func main() {
 			// Clear the form
 			newProviderForm.GetFormItem(0).(*tview.InputField).SetText("")
 			newProviderForm.GetFormItem(1).(*tview.InputField).SetText("")
+			newProviderForm.GetFormItem(3).(*tview.InputField).SetText("")
 
 			// Update the view and reset current OTP information
 			currentOTP = ""
@@ -329,13 +402,44 @@ func main() {
 	// Create a button to quit the application
 	quitButton := tview.NewButton("Quit").
 		SetSelectedFunc(func() {
+			zeroize(sessionKey)
 			app.Stop()
 		})
 
+	// Create a button to change the passphrase protecting providers.json
+	changePassphraseButton := tview.NewButton("Change Passphrase").
+		SetSelectedFunc(func() {
+			showChangePassphraseModal(app, providers, ProvidersFilePath, sessionKey, sessionSalt, func(newKey, newSalt []byte) {
+				zeroize(sessionKey)
+				sessionKey = newKey
+				sessionSalt = newSalt
+			}, func() {
+				app.SetRoot(mainFlex, true)
+			})
+		})
+
+	// Create a button to reach the Import/Export screen
+	importExportButton := tview.NewButton("Import/Export...").
+		SetSelectedFunc(func() {
+			showImportExportScreen(app, providers, sessionKey, sessionSalt, func(imported []Provider) {
+				for _, provider := range imported {
+					providers = append(providers, provider)
+					providerList.AddItem(provider.Name, "", rune('1'+len(providers)-1), nil)
+				}
+				if err := saveProviders(providers, ProvidersFilePath, sessionKey, sessionSalt); err != nil {
+					otpView.SetText(fmt.Sprintf("[red]Error saving imported providers: %s[white]", err.Error()))
+				}
+			}, func() {
+				app.SetRoot(mainFlex, true)
+			})
+		})
+
 	// Create a flex layout for buttons
 	buttonFlex := tview.NewFlex().
 		SetDirection(tview.FlexColumn).
 		AddItem(nil, 0, 1, false).
+		AddItem(changePassphraseButton, 0, 2, true).
+		AddItem(importExportButton, 0, 2, true).
 		AddItem(quitButton, 0, 2, true).
 		AddItem(nil, 0, 1, false)
 
@@ -355,7 +459,7 @@ func main() {
 		AddItem(buttonFlex, 3, 0, true)
 
 	// Create a flex layout for the entire UI
-	mainFlex := tview.NewFlex().
+	mainFlex = tview.NewFlex().
 		SetDirection(tview.FlexColumn).
 		AddItem(providerFlex, 0, 1, true).
 		AddItem(otpFlex, 0, 2, false)
@@ -369,7 +473,9 @@ func main() {
 			select {
 			case <-ticker.C:
 				app.QueueUpdateDraw(func() {
-					if len(providers) > 0 && selectedProviderIndex >= 0 {
+					// HOTP codes only advance when the user explicitly
+					// requests the next one, not on a timer
+					if len(providers) > 0 && selectedProviderIndex >= 0 && currentProviderType != ProviderTypeHOTP {
 						generateAndDisplayOTP()
 					}
 				})
@@ -394,8 +500,7 @@ func main() {
 
 	// No need to generate OTP initially as there are no providers
 
-	// Set the flex as the root of the application and start it
-	if err := app.SetRoot(mainFlex, true).EnableMouse(true).Run(); err != nil {
-		panic(err)
-	}
+	// Swap the passphrase prompt for the main UI; app.Run() is already
+	// blocking in main(), started once the startup flow called us.
+	app.SetRoot(mainFlex, true).EnableMouse(true)
 }
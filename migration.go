@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Google Authenticator's export QR encodes an otpauth-migration://offline
+// URI whose "data" query parameter is a base64-encoded protobuf
+// MigrationPayload message:
+//
+//	message MigrationPayload {
+//	  message OtpParameters {
+//	    bytes secret = 1;
+//	    string name = 2;
+//	    string issuer = 3;
+//	    Algorithm algorithm = 4; // 1=SHA1 2=SHA256 3=SHA512 4=MD5
+//	    DigitCount digits = 5;   // 1=six 2=eight
+//	    OtpType type = 6;        // 1=HOTP 2=TOTP
+//	    int64 counter = 7;
+//	  }
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2;
+//	  int32 batch_size = 3;
+//	  int32 batch_index = 4;
+//	  int32 batch_id = 5;
+//	}
+//
+// There's no protoc/toolchain available in this repo, so rather than vendor
+// a full codegen pipeline for one fixed, well-documented message, we read
+// the handful of fields we need directly off the wire.
+
+type migrationOtpParameters struct {
+	Secret    []byte
+	Name      string
+	Issuer    string
+	Algorithm int64
+	Digits    int64
+	Type      int64
+	Counter   int64
+}
+
+// migrationURIToProviders decodes an otpauth-migration://offline URI into
+// the Providers it describes.
+func migrationURIToProviders(uri string) ([]Provider, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration URI: %v", err)
+	}
+	if u.Scheme != "otpauth-migration" {
+		return nil, fmt.Errorf("not a migration URI: %s", uri)
+	}
+
+	encoded := u.Query().Get("data")
+	if encoded == "" {
+		return nil, fmt.Errorf("migration URI is missing the data parameter")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64-decode migration payload: %v", err)
+	}
+
+	params, err := parseMigrationPayload(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse migration payload: %v", err)
+	}
+
+	providers := make([]Provider, 0, len(params))
+	for _, p := range params {
+		if len(p.Secret) == 0 {
+			continue
+		}
+
+		algorithm, err := migrationAlgorithmName(p.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p.Name, err)
+		}
+
+		provider := Provider{
+			Name:      p.Name,
+			Issuer:    p.Issuer,
+			Secret:    base32.StdEncoding.EncodeToString(p.Secret),
+			Algorithm: algorithm,
+			Digits:    migrationDigitCount(p.Digits),
+			Period:    DefaultPeriod,
+			Type:      ProviderTypeTOTP,
+		}
+		if p.Type == 1 { // OTP_TYPE_HOTP
+			provider.Type = ProviderTypeHOTP
+			provider.Counter = uint64(p.Counter)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// migrationAlgorithmName maps a MigrationPayload Algorithm enum value to the
+// Key URI Format name the rest of the app understands. ALGORITHM_MD5 (4) is
+// rejected rather than silently folded into the default, matching how
+// algorithmFromString rejects algorithms it can't map.
+func migrationAlgorithmName(algorithm int64) (string, error) {
+	switch algorithm {
+	case 0, 1: // unspecified and ALGORITHM_SHA1 both map to the same default
+		return DefaultAlgorithm, nil
+	case 2:
+		return "SHA256", nil
+	case 3:
+		return "SHA512", nil
+	default:
+		return "", fmt.Errorf("unsupported migration algorithm: %d", algorithm)
+	}
+}
+
+func migrationDigitCount(digits int64) int {
+	if digits == 2 { // DIGIT_COUNT_EIGHT
+		return 8
+	}
+	return DefaultDigits
+}
+
+// parseMigrationPayload reads the repeated otp_parameters (field 1) out of
+// a MigrationPayload message, ignoring the batching fields this app has no
+// use for.
+func parseMigrationPayload(data []byte) ([]migrationOtpParameters, error) {
+	var params []migrationOtpParameters
+
+	fields, err := parseProtobufFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		if f.number != 1 || f.wireType != protobufWireBytes {
+			continue
+		}
+		param, err := parseOtpParameters(f.bytesValue)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+
+	return params, nil
+}
+
+func parseOtpParameters(data []byte) (migrationOtpParameters, error) {
+	var param migrationOtpParameters
+
+	fields, err := parseProtobufFields(data)
+	if err != nil {
+		return param, err
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			param.Secret = f.bytesValue
+		case 2:
+			param.Name = string(f.bytesValue)
+		case 3:
+			param.Issuer = string(f.bytesValue)
+		case 4:
+			param.Algorithm = f.varintValue
+		case 5:
+			param.Digits = f.varintValue
+		case 6:
+			param.Type = f.varintValue
+		case 7:
+			param.Counter = f.varintValue
+		}
+	}
+
+	return param, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+)
+
+// ProviderType identifies whether a Provider generates time-based or
+// counter-based one-time passwords.
+type ProviderType string
+
+const (
+	ProviderTypeTOTP ProviderType = "TOTP"
+	ProviderTypeHOTP ProviderType = "HOTP"
+)
+
+// Defaults applied when a secret or URI doesn't specify these explicitly,
+// matching the Key URI Format spec.
+const (
+	DefaultAlgorithm = "SHA1"
+	DefaultDigits    = 6
+	DefaultPeriod    = 30
+)
+
+// algorithmFromString maps the Key URI Format algorithm name to the
+// otp.Algorithm the pquerna/otp package expects.
+func algorithmFromString(name string) (otp.Algorithm, error) {
+	switch strings.ToUpper(name) {
+	case "", "SHA1":
+		return otp.AlgorithmSHA1, nil
+	case "SHA256":
+		return otp.AlgorithmSHA256, nil
+	case "SHA512":
+		return otp.AlgorithmSHA512, nil
+	default:
+		return otp.AlgorithmSHA1, fmt.Errorf("unsupported algorithm: %s", name)
+	}
+}
+
+// digitsFromInt maps a digit count to the otp.Digits the pquerna/otp
+// package expects.
+func digitsFromInt(n int) otp.Digits {
+	if n == 8 {
+		return otp.DigitsEight
+	}
+	return otp.DigitsSix
+}
+
+// ParseOTPAuthURI parses a Key URI Format string
+// (otpauth://totp/... or otpauth://hotp/...) into a Provider.
+func ParseOTPAuthURI(uri string) (Provider, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return Provider{}, fmt.Errorf("invalid otpauth URI: %v", err)
+	}
+	if u.Scheme != "otpauth" {
+		return Provider{}, fmt.Errorf("not an otpauth URI: %s", uri)
+	}
+
+	var providerType ProviderType
+	switch strings.ToLower(u.Host) {
+	case "totp":
+		providerType = ProviderTypeTOTP
+	case "hotp":
+		providerType = ProviderTypeHOTP
+	default:
+		return Provider{}, fmt.Errorf("unsupported otpauth type: %s", u.Host)
+	}
+
+	// The label is "Issuer:Account" or just "Account".
+	label := strings.TrimPrefix(u.Path, "/")
+	label, err = url.PathUnescape(label)
+	if err != nil {
+		return Provider{}, fmt.Errorf("invalid otpauth label: %v", err)
+	}
+
+	issuer := ""
+	name := label
+	if idx := strings.Index(label, ":"); idx != -1 {
+		issuer = strings.TrimSpace(label[:idx])
+		name = strings.TrimSpace(label[idx+1:])
+	}
+
+	query := u.Query()
+
+	secret := query.Get("secret")
+	if secret == "" {
+		return Provider{}, fmt.Errorf("otpauth URI is missing the secret parameter")
+	}
+
+	// A query issuer parameter takes precedence over the label prefix.
+	if queryIssuer := query.Get("issuer"); queryIssuer != "" {
+		issuer = queryIssuer
+	}
+
+	algorithm := DefaultAlgorithm
+	if a := query.Get("algorithm"); a != "" {
+		algorithm = strings.ToUpper(a)
+	}
+	if _, err := algorithmFromString(algorithm); err != nil {
+		return Provider{}, err
+	}
+
+	digits := DefaultDigits
+	if d := query.Get("digits"); d != "" {
+		digits, err = strconv.Atoi(d)
+		if err != nil {
+			return Provider{}, fmt.Errorf("invalid digits parameter: %v", err)
+		}
+		if digits != 6 && digits != 8 {
+			return Provider{}, fmt.Errorf("unsupported digits: %d", digits)
+		}
+	}
+
+	provider := Provider{
+		Name:      name,
+		Secret:    secret,
+		Issuer:    issuer,
+		Algorithm: algorithm,
+		Digits:    digits,
+		Type:      providerType,
+	}
+
+	switch providerType {
+	case ProviderTypeTOTP:
+		period := DefaultPeriod
+		if p := query.Get("period"); p != "" {
+			period, err = strconv.Atoi(p)
+			if err != nil {
+				return Provider{}, fmt.Errorf("invalid period parameter: %v", err)
+			}
+		}
+		provider.Period = period
+	case ProviderTypeHOTP:
+		counter := uint64(0)
+		if c := query.Get("counter"); c != "" {
+			counter, err = strconv.ParseUint(c, 10, 64)
+			if err != nil {
+				return Provider{}, fmt.Errorf("invalid counter parameter: %v", err)
+			}
+		}
+		provider.Counter = counter
+	}
+
+	return provider, nil
+}
+
+// generateTOTP generates the next one-time password for provider,
+// dispatching to the TOTP or HOTP algorithm it was configured with. For
+// HOTP providers, the caller is responsible for persisting the
+// incremented Counter this leaves on provider.
+func generateTOTP(provider *Provider) (string, error) {
+	algorithm, err := algorithmFromString(provider.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	digits := digitsFromInt(provider.Digits)
+
+	if provider.Type == ProviderTypeHOTP {
+		code, err := hotp.GenerateCodeCustom(provider.Secret, provider.Counter, hotp.ValidateOpts{
+			Digits:    digits,
+			Algorithm: algorithm,
+		})
+		if err != nil {
+			return "", err
+		}
+		provider.Counter++
+		return code, nil
+	}
+
+	period := provider.Period
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+
+	return totp.GenerateCodeCustom(provider.Secret, time.Now(), totp.ValidateOpts{
+		Period:    uint(period),
+		Digits:    digits,
+		Algorithm: algorithm,
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import "fmt"
+
+// Minimal protobuf wire-format reader, enough to pull varint and
+// length-delimited fields out of a message without a full codegen
+// pipeline. See migration.go for the one schema this is used for.
+
+const (
+	protobufWireVarint = 0
+	protobufWireBytes  = 2
+)
+
+type protobufField struct {
+	number      int
+	wireType    int
+	varintValue int64
+	bytesValue  []byte
+}
+
+// parseProtobufFields walks every top-level field in data. Wire types
+// other than varint and length-delimited (fixed32/fixed64) aren't used by
+// the MigrationPayload schema and are rejected rather than silently
+// mis-parsed.
+func parseProtobufFields(data []byte) ([]protobufField, error) {
+	var fields []protobufField
+
+	for len(data) > 0 {
+		tag, n, err := readVarint(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n:]
+
+		number := int(tag >> 3)
+		wireType := int(tag & 0x7)
+
+		var field protobufField
+		field.number = number
+		field.wireType = wireType
+
+		switch wireType {
+		case protobufWireVarint:
+			value, n, err := readVarint(data)
+			if err != nil {
+				return nil, err
+			}
+			data = data[n:]
+			field.varintValue = int64(value)
+		case protobufWireBytes:
+			length, n, err := readVarint(data)
+			if err != nil {
+				return nil, err
+			}
+			data = data[n:]
+			if uint64(len(data)) < length {
+				return nil, fmt.Errorf("truncated length-delimited field")
+			}
+			field.bytesValue = data[:length]
+			data = data[length:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type: %d", wireType)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// readVarint decodes a base-128 varint from the start of data, returning
+// its value and the number of bytes it occupied.
+func readVarint(data []byte) (uint64, int, error) {
+	var value uint64
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		value |= uint64(b&0x7f) << (7 * uint(i))
+		if b&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("truncated varint")
+}